@@ -0,0 +1,31 @@
+package config
+
+import "io"
+
+// Config holds the client-wide settings a driver needs but that aren't part
+// of any single task's config, e.g. where to find plugin binaries or shared
+// CNI assets.
+//
+// NOTE: this is a fixture standing in for the real client/config.Config,
+// which is not part of this tree's snapshot. In the full nomad repo these
+// fields (LogOutput already existed; CNIPath/CNIConfigDir are new) belong
+// on that existing struct alongside the rest of the client's configuration
+// -- not on a new, qemu-only struct -- and CNIPath/CNIConfigDir also need
+// the matching `client.cni_path`/`client.cni_config_dir` keys wired into
+// the agent's HCL config parser, which likewise isn't present here.
+type Config struct {
+	// LogOutput is the writer child plugin processes (e.g. the executor)
+	// sync their stdout/stderr to.
+	LogOutput io.Writer
+
+	// CNIPath is the directory CNI plugin binaries are installed in,
+	// searched when the qemu driver sets up bridge/CNI networking. It
+	// corresponds to the `client.cni_path` agent config key and defaults to
+	// defaultCNIPath when unset.
+	CNIPath string
+
+	// CNIConfigDir is the directory CNI network configuration lists are
+	// read from. It corresponds to the `client.cni_config_dir` agent config
+	// key and defaults to defaultCNIConfigDir when unset.
+	CNIConfigDir string
+}
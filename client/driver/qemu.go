@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-plugin"
@@ -27,6 +28,20 @@ var (
 	reQemuVersion = regexp.MustCompile(`version (\d[\.\d+]+)`)
 )
 
+const (
+	// qmpSocketName is the name of the QMP control socket created in the
+	// task's local directory for each VM.
+	qmpSocketName = "qmp.sock"
+
+	// qmpDialTimeout bounds how long we wait to connect to a VM's QMP
+	// socket before giving up and falling back to killing the executor.
+	qmpDialTimeout = 5 * time.Second
+
+	// qmpPollInterval is how often Kill polls query-status while waiting
+	// for a guest to respond to an ACPI shutdown or quit request.
+	qmpPollInterval = 500 * time.Millisecond
+)
+
 // QemuDriver is a driver for running images via Qemu
 // We attempt to chose sane defaults for now, with more configuration available
 // planned in the future
@@ -36,21 +51,51 @@ type QemuDriver struct {
 }
 
 type QemuDriverConfig struct {
-	ArtifactSource string           `mapstructure:"artifact_source"`
-	Checksum       string           `mapstructure:"checksum"`
-	Accelerator    string           `mapstructure:"accelerator"`
-	PortMap        []map[string]int `mapstructure:"port_map"` // A map of host port labels and to guest ports.
+	ArtifactSource string            `mapstructure:"artifact_source"`
+	Checksum       string            `mapstructure:"checksum"`
+	Accelerator    string            `mapstructure:"accelerator"`
+	PortMap        []map[string]int  `mapstructure:"port_map"` // A map of host port labels and to guest ports.
+	Drives         []QemuDriveConfig `mapstructure:"drive"`
+	NICs           []QemuNICConfig   `mapstructure:"nic"`
+	CDROM          *QemuCDROMConfig  `mapstructure:"cdrom"`
+	Machine        QemuMachineConfig `mapstructure:"machine"`
+	CPU            QemuCPUConfig     `mapstructure:"cpu"`
+	ExtraArgs      []string          `mapstructure:"extra_args"`
+	Network        QemuNetworkConfig `mapstructure:"network"`
+	Snapshot       bool              `mapstructure:"snapshot"`
+	BackingFormat  string            `mapstructure:"backing_format"`
+	CloudInit      *CloudInitConfig  `mapstructure:"cloud_init"`
 }
 
 // qemuHandle is returned from Start/Open as a handle to the PID
 type qemuHandle struct {
-	pluginClient *plugin.Client
-	userPid      int
-	executor     plugins.Executor
-	killTimeout  time.Duration
-	logger       *log.Logger
-	waitCh       chan *cstructs.WaitResult
-	doneCh       chan struct{}
+	pluginClient  *plugin.Client
+	userPid       int
+	executor      plugins.Executor
+	qmpSocketPath string
+	killTimeout   time.Duration
+	logger        *log.Logger
+	waitCh        chan *cstructs.WaitResult
+	doneCh        chan struct{}
+
+	// netNS is only set when the task uses bridge/CNI networking; together
+	// with containerID and the cni* fields (which are recorded in every
+	// mode) it lets teardownNetwork tear that networking back down,
+	// whether that happens because Kill was called or the VM exited on its
+	// own. networkTeardownOnce makes that teardown safe to trigger from
+	// both paths without double-running CNI DEL.
+	netNS               string
+	containerID         string
+	cniPath             string
+	cniConfigDir        string
+	cniNetwork          string
+	networkIPs          []string
+	networkTeardownOnce sync.Once
+
+	// migrationState/migrationMu track the progress of an in-flight or
+	// completed Snapshot/Migrate call.
+	migrationMu    sync.Mutex
+	migrationState string
 }
 
 // NewQemuDriver is used to create a new exec driver
@@ -79,12 +124,95 @@ func (d *QemuDriver) Fingerprint(cfg *config.Config, node *structs.Node) (bool,
 	node.Attributes["driver.qemu"] = "1"
 	node.Attributes["driver.qemu.version"] = matches[1]
 
+	// Advertise the configuration knobs this driver version understands so
+	// schedulers/operators can tell drives, NICs, machine, and CPU topology
+	// are pluggable rather than hard-coded.
+	node.Attributes["driver.qemu.drives"] = "1"
+	node.Attributes["driver.qemu.nics"] = "1"
+	node.Attributes["driver.qemu.cdrom"] = "1"
+	node.Attributes["driver.qemu.machine"] = "1"
+	node.Attributes["driver.qemu.cpu"] = "1"
+
+	// qemu-img is used to create qcow2 overlays for the snapshot/backing
+	// file workflow; fingerprint it separately since it ships as its own
+	// binary and can be missing even when qemu-system-x86_64 is present.
+	if imgOut, err := exec.Command("qemu-img", "--version").Output(); err == nil {
+		imgVersion := reQemuVersion.FindStringSubmatch(strings.TrimSpace(string(imgOut)))
+		if len(imgVersion) == 2 {
+			node.Attributes["driver.qemu.img_version"] = imgVersion[1]
+		}
+	}
+
 	return true, nil
 }
 
+// qemuValidAccelerators enumerates the `accelerator` values Qemu supports
+// across the platforms Nomad runs on.
+var qemuValidAccelerators = map[string]bool{
+	"tcg": true,
+	"kvm": true,
+	"hax": true,
+	"hvf": true,
+}
+
+// Validate is called by the jobspec parser at submit time so malformed or
+// unknown config surfaces immediately instead of only failing on a client
+// once the task is placed. It deliberately decodes more strictly than
+// start does: ErrorUnused rejects typo'd/unknown keys here, at submit time,
+// rather than letting start's permissive WeakDecode silently drop them and
+// only notice something is wrong once the task is placed on a client.
+//
+// NOTE: Validate is not part of the Driver interface, so only the qemu
+// driver's own submit-time validation (invoked by its jobspec stanza
+// parser) calls this. This change does not add an equivalent hook to any
+// other driver -- this tree contains no other drivers to wire one into --
+// so parse-time validation remains qemu-only until that's done elsewhere.
+func (d *QemuDriver) Validate(config map[string]interface{}) error {
+	var driverConfig QemuDriverConfig
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused: true,
+		Result:      &driverConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return err
+	}
+
+	if driverConfig.ArtifactSource == "" {
+		return fmt.Errorf("artifact_source must be set")
+	}
+
+	if driverConfig.Accelerator != "" && !qemuValidAccelerators[driverConfig.Accelerator] {
+		return fmt.Errorf("invalid accelerator %q", driverConfig.Accelerator)
+	}
+
+	if len(driverConfig.PortMap) > 1 {
+		return fmt.Errorf("Only one port_map block is allowed in the qemu driver config")
+	}
+
+	if len(driverConfig.PortMap) == 1 {
+		for label := range driverConfig.PortMap[0] {
+			if label == "" {
+				return fmt.Errorf("port_map: port labels cannot be empty")
+			}
+		}
+	}
+
+	return driverConfig.validate()
+}
+
 // Run an existing Qemu image. Start() will pull down an existing, valid Qemu
 // image and save it to the Drivers Allocation Dir
 func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle, error) {
+	return d.start(ctx, task, "")
+}
+
+// start is the shared implementation behind Start and Restore; incoming,
+// when set, is passed to Qemu as the `-incoming` migration URI so the VM
+// boots waiting for state to arrive instead of running a fresh guest.
+func (d *QemuDriver) start(ctx *ExecContext, task *structs.Task, incoming string) (DriverHandle, error) {
 	var driverConfig QemuDriverConfig
 	if err := mapstructure.WeakDecode(task.Config, &driverConfig); err != nil {
 		return nil, err
@@ -94,6 +222,10 @@ func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 		return nil, fmt.Errorf("Only one port_map block is allowed in the qemu driver config")
 	}
 
+	if err := driverConfig.validate(); err != nil {
+		return nil, err
+	}
+
 	// Get the image source
 	source, ok := task.Config["artifact_source"]
 	if !ok || source == "" {
@@ -125,6 +257,22 @@ func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 
 	vmID := filepath.Base(vmPath)
 
+	// When snapshotting, boot from a qcow2 overlay instead of the
+	// downloaded artifact so the golden image is never mutated and other
+	// allocations can keep sharing it.
+	if driverConfig.Snapshot {
+		vmPath, err = createOverlayDisk(vmPath, taskDir, driverConfig.BackingFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Qemu is instructed to listen on this socket for QMP (the Qemu Machine
+	// Protocol), which is used as the primary lifecycle channel -- e.g. to
+	// request a graceful ACPI shutdown in Kill -- instead of only relying on
+	// the wrapping executor's PID.
+	qmpSocketPath := filepath.Join(taskDir, qmpSocketName)
+
 	// Parse configuration arguments
 	// Create the base arguments
 	accelerator := "tcg"
@@ -134,47 +282,95 @@ func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 	// TODO: Check a lower bounds, e.g. the default 128 of Qemu
 	mem := fmt.Sprintf("%dM", task.Resources.MemoryMB)
 
+	machineType := "pc"
+	if driverConfig.Machine.Type != "" {
+		machineType = driverConfig.Machine.Type
+	}
+	machine := fmt.Sprintf("type=%s,accel=%s", machineType, accelerator)
+	if driverConfig.Machine.AccelOptions != "" {
+		machine = machine + "," + driverConfig.Machine.AccelOptions
+	}
+
 	args := []string{
 		"qemu-system-x86_64",
-		"-machine", "type=pc,accel=" + accelerator,
+		"-machine", machine,
 		"-name", vmID,
 		"-m", mem,
-		"-drive", "file=" + vmPath,
 		"-nodefconfig",
 		"-nodefaults",
 		"-nographic",
+		"-qmp", fmt.Sprintf("unix:%s,server=on,wait=off", qmpSocketPath),
 	}
+	args = append(args, driverConfig.driveArgs(vmPath)...)
 
-	// Check the Resources required Networks to add port mappings. If no resources
-	// are required, we assume the VM is a purely compute job and does not require
-	// the outside world to be able to reach it. VMs ran without port mappings can
-	// still reach out to the world, but without port mappings it is effectively
-	// firewalled
-	protocols := []string{"udp", "tcp"}
-	if len(task.Resources.Networks) > 0 && len(driverConfig.PortMap) == 1 {
-		// Loop through the port map and construct the hostfwd string, to map
-		// reserved ports to the ports listenting in the VM
-		// Ex: hostfwd=tcp::22000-:22,hostfwd=tcp::80-:8080
-		var forwarding []string
-		taskPorts := task.Resources.Networks[0].MapLabelToValues(nil)
-		for label, guest := range driverConfig.PortMap[0] {
-			host, ok := taskPorts[label]
-			if !ok {
-				return nil, fmt.Errorf("Unknown port label %q", label)
-			}
+	if cpu := driverConfig.CPU; cpu.Sockets > 0 || cpu.Cores > 0 || cpu.Threads > 0 {
+		n := cpu.Sockets * cpu.Cores * cpu.Threads
+		if n == 0 {
+			n = 1
+		}
+		smp := fmt.Sprintf("%d", n)
+		if cpu.Sockets > 0 {
+			smp = smp + fmt.Sprintf(",sockets=%d", cpu.Sockets)
+		}
+		if cpu.Cores > 0 {
+			smp = smp + fmt.Sprintf(",cores=%d", cpu.Cores)
+		}
+		if cpu.Threads > 0 {
+			smp = smp + fmt.Sprintf(",threads=%d", cpu.Threads)
+		}
+		args = append(args, "-smp", smp)
+	}
 
-			for _, p := range protocols {
-				forwarding = append(forwarding, fmt.Sprintf("hostfwd=%s::%d-:%d", p, host, guest))
-			}
+	if driverConfig.CDROM != nil {
+		isoPath, err := getter.GetArtifact(
+			filepath.Join(taskDir, allocdir.TaskLocal),
+			driverConfig.CDROM.ArtifactSource,
+			driverConfig.CDROM.Checksum,
+			d.logger,
+		)
+		if err != nil {
+			return nil, err
 		}
+		args = append(args, "-drive", fmt.Sprintf("file=%s,media=cdrom", isoPath))
+	}
 
-		if len(forwarding) != 0 {
-			args = append(args,
-				"-netdev",
-				fmt.Sprintf("user,id=user.0,%s", strings.Join(forwarding, ",")),
-				"-device", "virtio-net,netdev=user.0",
-			)
+	if driverConfig.CloudInit != nil {
+		seedPath := filepath.Join(taskDir, cloudInitISOName)
+		if err := buildCloudInitISO(*driverConfig.CloudInit, seedPath); err != nil {
+			return nil, fmt.Errorf("failed to build cloud-init seed ISO: %v", err)
 		}
+		args = append(args, "-drive", fmt.Sprintf("file=%s,media=cdrom", seedPath))
+	}
+
+	// netNS is set when the task uses bridge/CNI networking; the executor
+	// must launch Qemu inside it so the TAP device CNI created is visible.
+	var netNS string
+	var networkIPs []string
+	containerID := fmt.Sprintf("%s-%s", filepath.Base(filepath.Dir(taskDir)), task.Name)
+
+	switch driverConfig.Network.Mode {
+	case networkModeBridge, networkModeCNI:
+		cniResult, err := setupCNINetwork(d.config.CNIPath, d.config.CNIConfigDir, driverConfig.Network.CNINetwork, containerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up %s networking: %v", driverConfig.Network.Mode, err)
+		}
+		netNS = cniResult.NetNS
+		networkIPs = cniResult.IPs
+		args = append(args,
+			"-netdev", fmt.Sprintf("tap,id=net0,ifname=%s,script=no,downscript=no", cniResult.TapDevice),
+			"-device", "virtio-net-pci,netdev=net0,mac="+cniResult.MAC,
+		)
+	default:
+		// Check the Resources required Networks to add port mappings. If no
+		// resources are required, we assume the VM is a purely compute job and
+		// does not require the outside world to be able to reach it. VMs ran
+		// without port mappings can still reach out to the world, but without
+		// port mappings it is effectively firewalled.
+		netArgs, err := driverConfig.netArgs(task.Resources.Networks, driverConfig.PortMap)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, netArgs...)
 	}
 
 	// If using KVM, add optimization args
@@ -182,11 +378,15 @@ func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 		args = append(args,
 			"-enable-kvm",
 			"-cpu", "host",
-			// Do we have cores information available to the Driver?
-			// "-smp", fmt.Sprintf("%d", cores),
 		)
 	}
 
+	if incoming != "" {
+		args = append(args, "-incoming", incoming)
+	}
+
+	args = append(args, driverConfig.ExtraArgs...)
+
 	d.logger.Printf("[DEBUG] Starting QemuVM command: %q", strings.Join(args, " "))
 	bin, err := discover.NomadExecutable()
 	if err != nil {
@@ -206,9 +406,19 @@ func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 		TaskName:      task.Name,
 		TaskResources: task.Resources,
 	}
-	ps, err := executor.LaunchCmd(&plugins.ExecCommand{Cmd: args[0], Args: args[1:]}, executorCtx)
+
+	launchCmd, launchArgs := args[0], args[1:]
+	if netNS != "" {
+		// Run qemu inside the namespace CNI configured so it sees the TAP
+		// device and routes that were set up there.
+		launchCmd, launchArgs = nsenterArgs(netNS, launchCmd, launchArgs)
+	}
+	ps, err := executor.LaunchCmd(&plugins.ExecCommand{Cmd: launchCmd, Args: launchArgs}, executorCtx)
 	if err != nil {
 		pluginClient.Kill()
+		if netNS != "" {
+			teardownCNINetwork(d.config.CNIPath, d.config.CNIConfigDir, driverConfig.Network.CNINetwork, containerID, netNS)
+		}
 		return nil, fmt.Errorf("error starting process via the plugin: %v", err)
 	}
 	d.logger.Printf("[INFO] started process with pid: %v", ps.Pid)
@@ -216,13 +426,20 @@ func (d *QemuDriver) Start(ctx *ExecContext, task *structs.Task) (DriverHandle,
 
 	// Create and Return Handle
 	h := &qemuHandle{
-		pluginClient: pluginClient,
-		executor:     executor,
-		userPid:      ps.Pid,
-		killTimeout:  d.DriverContext.KillTimeout(task),
-		logger:       d.logger,
-		doneCh:       make(chan struct{}),
-		waitCh:       make(chan *cstructs.WaitResult, 1),
+		pluginClient:  pluginClient,
+		executor:      executor,
+		userPid:       ps.Pid,
+		qmpSocketPath: qmpSocketPath,
+		killTimeout:   d.DriverContext.KillTimeout(task),
+		logger:        d.logger,
+		doneCh:        make(chan struct{}),
+		waitCh:        make(chan *cstructs.WaitResult, 1),
+		netNS:         netNS,
+		containerID:   containerID,
+		cniPath:       d.config.CNIPath,
+		cniConfigDir:  d.config.CNIConfigDir,
+		cniNetwork:    driverConfig.Network.CNINetwork,
+		networkIPs:    networkIPs,
 	}
 
 	go h.run()
@@ -250,9 +467,17 @@ func (d *QemuDriver) executor(config *plugin.ClientConfig) (plugins.Executor, *p
 }
 
 type qemuId struct {
-	KillTimeout  time.Duration
-	UserPid      int
-	PluginConfig *plugins.ExecutorReattachConfig
+	KillTimeout    time.Duration
+	UserPid        int
+	QmpSocketPath  string
+	NetNS          string
+	ContainerID    string
+	CNIPath        string
+	CNIConfigDir   string
+	CNINetwork     string
+	NetworkIPs     []string
+	MigrationState string
+	PluginConfig   *plugins.ExecutorReattachConfig
 }
 
 func (d *QemuDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, error) {
@@ -273,13 +498,21 @@ func (d *QemuDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, erro
 
 	// Return a driver handle
 	h := &qemuHandle{
-		pluginClient: client,
-		executor:     executor,
-		userPid:      id.UserPid,
-		logger:       d.logger,
-		killTimeout:  id.KillTimeout,
-		doneCh:       make(chan struct{}),
-		waitCh:       make(chan *cstructs.WaitResult, 1),
+		pluginClient:   client,
+		executor:       executor,
+		userPid:        id.UserPid,
+		qmpSocketPath:  id.QmpSocketPath,
+		logger:         d.logger,
+		killTimeout:    id.KillTimeout,
+		doneCh:         make(chan struct{}),
+		waitCh:         make(chan *cstructs.WaitResult, 1),
+		netNS:          id.NetNS,
+		containerID:    id.ContainerID,
+		cniPath:        id.CNIPath,
+		cniConfigDir:   id.CNIConfigDir,
+		cniNetwork:     id.CNINetwork,
+		networkIPs:     id.NetworkIPs,
+		migrationState: id.MigrationState,
 	}
 	go h.run()
 	return h, nil
@@ -287,9 +520,17 @@ func (d *QemuDriver) Open(ctx *ExecContext, handleID string) (DriverHandle, erro
 
 func (h *qemuHandle) ID() string {
 	id := qemuId{
-		KillTimeout:  h.killTimeout,
-		PluginConfig: plugins.NewExecutorReattachConfig(h.pluginClient.ReattachConfig()),
-		UserPid:      h.userPid,
+		KillTimeout:    h.killTimeout,
+		PluginConfig:   plugins.NewExecutorReattachConfig(h.pluginClient.ReattachConfig()),
+		UserPid:        h.userPid,
+		QmpSocketPath:  h.qmpSocketPath,
+		NetNS:          h.netNS,
+		ContainerID:    h.containerID,
+		CNIPath:        h.cniPath,
+		CNIConfigDir:   h.cniConfigDir,
+		CNINetwork:     h.cniNetwork,
+		NetworkIPs:     h.networkIPs,
+		MigrationState: h.MigrationStatus(),
 	}
 
 	data, err := json.Marshal(id)
@@ -303,6 +544,12 @@ func (h *qemuHandle) WaitCh() chan *cstructs.WaitResult {
 	return h.waitCh
 }
 
+// NetworkIPs returns the IP(s) CNI's IPAM plugin allocated to the VM when
+// it uses bridge/CNI networking, or nil otherwise.
+func (h *qemuHandle) NetworkIPs() []string {
+	return h.networkIPs
+}
+
 func (h *qemuHandle) Update(task *structs.Task) error {
 	// Store the updated kill timeout.
 	h.killTimeout = task.KillTimeout
@@ -311,22 +558,95 @@ func (h *qemuHandle) Update(task *structs.Task) error {
 	return nil
 }
 
-// TODO: allow a 'shutdown_command' that can be executed over a ssh connection
-// to the VM
+// Kill first attempts a graceful ACPI shutdown over the VM's QMP socket,
+// falls back to a QMP "quit" if the guest doesn't respond, and only as a
+// last resort kills the wrapping executor process. This works for any guest
+// OS since it does not depend on an in-guest shutdown command.
 func (h *qemuHandle) Kill() error {
+	defer h.teardownNetwork()
+
+	// killTimeout is a hard upper bound on Kill as a whole, not on each
+	// individual escalation step, so a single deadline is shared across
+	// the powerdown -> quit -> executor kill sequence below.
+	deadline := time.Now().Add(h.killTimeout)
+
+	if h.qmpSocketPath != "" {
+		client, err := dialQMP(h.qmpSocketPath, qmpDialTimeout)
+		if err != nil {
+			h.logger.Printf("[WARN] driver.qemu: failed to dial QMP socket %q, falling back to executor kill: %v", h.qmpSocketPath, err)
+		} else {
+			defer client.Close()
+
+			if err := client.SystemPowerdown(); err != nil {
+				h.logger.Printf("[WARN] driver.qemu: QMP system_powerdown failed: %v", err)
+			} else if h.waitExit(client, deadline) {
+				return nil
+			}
+
+			if err := client.Quit(); err != nil {
+				h.logger.Printf("[WARN] driver.qemu: QMP quit failed: %v", err)
+			} else if h.waitExit(client, deadline) {
+				return nil
+			}
+		}
+	}
+
 	h.executor.ShutDown()
 	select {
 	case <-h.doneCh:
 		return nil
-	case <-time.After(h.killTimeout):
+	case <-time.After(time.Until(deadline)):
 		return h.executor.Exit()
 	}
 }
 
+// waitExit blocks until the executor has exited (observed via doneCh) or
+// deadline passes, polling query-status over QMP in the meantime so we
+// don't have to rely solely on the wrapper PID to know the guest is gone:
+// once the guest reports it is no longer running, there's no reason to
+// keep waiting on the executor to notice the same thing.
+func (h *qemuHandle) waitExit(client *qmpClient, deadline time.Time) bool {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	ticker := time.NewTicker(qmpPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.doneCh:
+			return true
+		case <-timer.C:
+			return false
+		case <-ticker.C:
+			// A query-status error usually means the socket has gone away
+			// because Qemu already exited; doneCh will confirm shortly.
+			if status, err := client.QueryStatus(); err == nil && !status.Running {
+				return true
+			}
+		}
+	}
+}
+
 func (h *qemuHandle) run() {
 	ps, err := h.executor.Wait()
 	close(h.doneCh)
+	h.teardownNetwork()
 	h.waitCh <- &cstructs.WaitResult{ExitCode: ps.ExitCode, Signal: 0, Err: err}
 	close(h.waitCh)
 	h.pluginClient.Kill()
 }
+
+// teardownNetwork tears down the network namespace/CNI allocation set up
+// for bridge/CNI networking, if any. It runs exactly once regardless of
+// whether Kill or a natural guest exit (run) triggers it first, so the VM's
+// network resources are always reclaimed without double-running CNI DEL.
+func (h *qemuHandle) teardownNetwork() {
+	if h.netNS == "" {
+		return
+	}
+	h.networkTeardownOnce.Do(func() {
+		if err := teardownCNINetwork(h.cniPath, h.cniConfigDir, h.cniNetwork, h.containerID, h.netNS); err != nil {
+			h.logger.Printf("[WARN] driver.qemu: failed to tear down network namespace %q: %v", h.netNS, err)
+		}
+	})
+}
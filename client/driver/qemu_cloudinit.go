@@ -0,0 +1,79 @@
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+)
+
+// cloudInitISOName is the seed ISO Qemu attaches as an additional, read-only
+// CD-ROM drive (after the disks and any configured cdrom) so cloud-init's
+// NoCloud datasource can pick it up.
+const cloudInitISOName = "cidata.iso"
+
+// CloudInitConfig holds the NoCloud seed data to inject into a VM at boot.
+// Nomad never bakes credentials into the golden image; instead it hands
+// them to the guest via this seed, the same way other qemu-based VM
+// tooling provisions cloud images.
+type CloudInitConfig struct {
+	UserData      string `mapstructure:"user_data"`
+	MetaData      string `mapstructure:"meta_data"`
+	NetworkConfig string `mapstructure:"network_config"`
+}
+
+// buildCloudInitISO renders a NoCloud seed ISO at destPath containing
+// user-data, meta-data, and (if set) network-config. It shells out to
+// genisoimage/mkisofs when available, and otherwise falls back to a small
+// pure-Go ISO9660 writer so the driver doesn't take on those as hard
+// dependencies.
+func buildCloudInitISO(ci CloudInitConfig, destPath string) error {
+	files, err := writeCloudInitFiles(filepath.Dir(destPath), ci)
+	if err != nil {
+		return err
+	}
+
+	if bin, err := exec.LookPath("genisoimage"); err == nil {
+		return runISOTool(bin, destPath, files)
+	}
+	if bin, err := exec.LookPath("mkisofs"); err == nil {
+		return runISOTool(bin, destPath, files)
+	}
+
+	return writeNoCloudISO(destPath, files)
+}
+
+// writeCloudInitFiles stages the NoCloud source files on disk next to the
+// ISO so either the external tool or the fallback writer can read them.
+func writeCloudInitFiles(dir string, ci CloudInitConfig) (map[string]string, error) {
+	files := map[string]string{
+		"user-data": ci.UserData,
+		"meta-data": ci.MetaData,
+	}
+	if ci.NetworkConfig != "" {
+		files["network-config"] = ci.NetworkConfig
+	}
+
+	paths := make(map[string]string, len(files))
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write cloud-init %s: %v", name, err)
+		}
+		paths[name] = path
+	}
+	return paths, nil
+}
+
+func runISOTool(bin, destPath string, files map[string]string) error {
+	args := []string{"-output", destPath, "-volid", "CIDATA", "-joliet", "-rock"}
+	for name, path := range files {
+		args = append(args, "-graft-points", name+"="+path)
+	}
+
+	cmd := exec.Command(bin, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed to build cloud-init ISO: %v: %s", bin, err, out)
+	}
+	return nil
+}
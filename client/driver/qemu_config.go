@@ -0,0 +1,204 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// qemuValidDriveInterfaces enumerates the drive interfaces the driver will
+// pass through to Qemu's `-drive` flag.
+var qemuValidDriveInterfaces = map[string]bool{
+	"ide":         true,
+	"scsi":        true,
+	"virtio":      true,
+	"virtio-scsi": true,
+}
+
+// qemuValidDriveCaches enumerates the supported `-drive cache=` modes.
+var qemuValidDriveCaches = map[string]bool{
+	"writethrough": true,
+	"writeback":    true,
+	"none":         true,
+	"unsafe":       true,
+	"directsync":   true,
+}
+
+// qemuValidDriveDiscards enumerates the supported `-drive discard=` modes.
+var qemuValidDriveDiscards = map[string]bool{
+	"unmap":  true,
+	"ignore": true,
+}
+
+// qemuValidNICModels is the allowlist of `-device` models the driver will
+// attach a NIC as, mirroring the models packer's qemu builder supports.
+var qemuValidNICModels = map[string]bool{
+	"virtio-net-pci": true,
+	"e1000":          true,
+	"rtl8139":        true,
+	"vmxnet3":        true,
+}
+
+// QemuDriveConfig describes a single disk to attach to the VM. The artifact
+// downloaded by the driver backs the first drive unless that entry sets
+// Path; additional entries describe extra disks already present on the host.
+type QemuDriveConfig struct {
+	Path      string `mapstructure:"path"`
+	Interface string `mapstructure:"interface"`
+	Cache     string `mapstructure:"cache"`
+	Discard   string `mapstructure:"discard"`
+	ReadOnly  bool   `mapstructure:"readonly"`
+	Format    string `mapstructure:"format"`
+}
+
+// QemuNICConfig describes a single NIC to attach to the VM.
+type QemuNICConfig struct {
+	Model string `mapstructure:"model"`
+	MAC   string `mapstructure:"mac"`
+}
+
+// QemuCDROMConfig describes an ISO artifact to download and attach as a
+// read-only CD-ROM drive.
+type QemuCDROMConfig struct {
+	ArtifactSource string `mapstructure:"artifact_source"`
+	Checksum       string `mapstructure:"checksum"`
+}
+
+// QemuMachineConfig overrides the `-machine` flag's type and any extra,
+// comma separated accelerator options (e.g. "kernel-irqchip=on").
+type QemuMachineConfig struct {
+	Type         string `mapstructure:"type"`
+	AccelOptions string `mapstructure:"accel_options"`
+}
+
+// QemuCPUConfig maps to Qemu's `-smp` flag.
+type QemuCPUConfig struct {
+	Sockets int `mapstructure:"sockets"`
+	Cores   int `mapstructure:"cores"`
+	Threads int `mapstructure:"threads"`
+}
+
+// validate checks the enumerated fields of the driver config up front so
+// that bad values surface before Qemu is ever exec'd.
+func (c *QemuDriverConfig) validate() error {
+	for i, drive := range c.Drives {
+		if drive.Interface != "" && !qemuValidDriveInterfaces[drive.Interface] {
+			return fmt.Errorf("drive %d: invalid interface %q", i, drive.Interface)
+		}
+		if drive.Cache != "" && !qemuValidDriveCaches[drive.Cache] {
+			return fmt.Errorf("drive %d: invalid cache mode %q", i, drive.Cache)
+		}
+		if drive.Discard != "" && !qemuValidDriveDiscards[drive.Discard] {
+			return fmt.Errorf("drive %d: invalid discard mode %q", i, drive.Discard)
+		}
+		if i > 0 && drive.Path == "" {
+			return fmt.Errorf("drive %d: path is required for additional drives", i)
+		}
+	}
+
+	for i, nic := range c.NICs {
+		if nic.Model != "" && !qemuValidNICModels[nic.Model] {
+			return fmt.Errorf("nic %d: invalid model %q", i, nic.Model)
+		}
+	}
+
+	if !qemuValidNetworkModes[c.Network.Mode] {
+		return fmt.Errorf("network: invalid mode %q", c.Network.Mode)
+	}
+
+	return nil
+}
+
+// driveArgs renders the `-drive` flags for the boot image and any
+// additional configured drives. vmPath is the artifact the driver
+// downloaded; it backs the first drive unless that entry overrides Path.
+func (c *QemuDriverConfig) driveArgs(vmPath string) []string {
+	drives := c.Drives
+	if len(drives) == 0 {
+		drives = []QemuDriveConfig{{}}
+	}
+
+	var args []string
+	for i, drive := range drives {
+		path := drive.Path
+		if i == 0 && path == "" {
+			path = vmPath
+		}
+
+		opts := []string{"file=" + path}
+		if drive.Interface != "" {
+			opts = append(opts, "if="+drive.Interface)
+		}
+		if drive.Cache != "" {
+			opts = append(opts, "cache="+drive.Cache)
+		}
+		if drive.Discard != "" {
+			opts = append(opts, "discard="+drive.Discard)
+		}
+		if drive.Format != "" {
+			opts = append(opts, "format="+drive.Format)
+		}
+		if drive.ReadOnly {
+			opts = append(opts, "readonly=on")
+		}
+
+		args = append(args, "-drive", strings.Join(opts, ","))
+	}
+
+	return args
+}
+
+// netArgs renders the `-netdev`/`-device` flag pairs for the VM's NICs,
+// forwarding the task's port_map (if any) as hostfwd rules on the first
+// NIC. User mode networking is the only mode supported here; bridged/CNI
+// networking is configured separately via network.mode.
+func (c *QemuDriverConfig) netArgs(networks structs.Networks, portMap []map[string]int) ([]string, error) {
+	nics := c.NICs
+	if len(nics) == 0 {
+		nics = []QemuNICConfig{{}}
+	}
+
+	protocols := []string{"udp", "tcp"}
+	var args []string
+	for i, nic := range nics {
+		model := nic.Model
+		if model == "" {
+			model = "virtio-net"
+		}
+		netdevID := fmt.Sprintf("net%d", i)
+
+		var forwarding []string
+		if i == 0 && len(networks) > 0 && len(portMap) == 1 {
+			taskPorts := networks[0].MapLabelToValues(nil)
+			for label, guest := range portMap[0] {
+				host, ok := taskPorts[label]
+				if !ok {
+					return nil, fmt.Errorf("Unknown port label %q", label)
+				}
+				for _, p := range protocols {
+					forwarding = append(forwarding, fmt.Sprintf("hostfwd=%s::%d-:%d", p, host, guest))
+				}
+			}
+		}
+
+		netdev := fmt.Sprintf("user,id=%s", netdevID)
+		if len(forwarding) != 0 {
+			netdev = netdev + "," + strings.Join(forwarding, ",")
+		}
+
+		device := fmt.Sprintf("%s,netdev=%s", model, netdevID)
+		if nic.MAC != "" {
+			device = device + ",mac=" + nic.MAC
+		}
+
+		// Preserve the legacy behaviour of only emitting a netdev/device
+		// pair when there is something to forward or the NIC was
+		// explicitly configured.
+		if len(forwarding) != 0 || len(c.NICs) != 0 {
+			args = append(args, "-netdev", netdev, "-device", device)
+		}
+	}
+
+	return args, nil
+}
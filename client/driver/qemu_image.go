@@ -0,0 +1,33 @@
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// overlayDiskName is the qcow2 overlay Qemu boots from when snapshotting is
+// enabled, leaving the downloaded artifact untouched.
+const overlayDiskName = "disk.qcow2"
+
+// createOverlayDisk creates a qcow2 overlay backed by basePath so the
+// golden image named by basePath is never written to; multiple
+// allocations can share it via their own copy-on-write overlay.
+func createOverlayDisk(basePath, taskDir, backingFormat string) (string, error) {
+	if backingFormat == "" {
+		backingFormat = "raw"
+	}
+
+	overlayPath := filepath.Join(taskDir, overlayDiskName)
+	cmd := exec.Command("qemu-img", "create",
+		"-f", "qcow2",
+		"-F", backingFormat,
+		"-b", basePath,
+		overlayPath,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create qcow2 overlay for %q: %v: %s", basePath, err, out)
+	}
+
+	return overlayPath, nil
+}
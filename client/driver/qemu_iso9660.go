@@ -0,0 +1,266 @@
+package driver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// This file implements just enough of ISO9660 (ECMA-119) plus the Rock
+// Ridge (SUSP/RRIP) extension needed to carry POSIX file names through a
+// flat, single-directory "CIDATA" volume for cloud-init's NoCloud
+// datasource. Rock Ridge is what lets `user-data`/`meta-data` survive as
+// written instead of being truncated to 8.3 names -- without it those
+// names wouldn't round-trip and the datasource would never find them.
+// genisoimage/mkisofs are used instead whenever either is available on the
+// host; this is only the fallback.
+
+const isoSectorSize = 2048
+
+// writeNoCloudISO builds a minimal ISO9660+Rock Ridge image at destPath
+// containing the files named in the sources map (file name -> path to its
+// contents on disk).
+func writeNoCloudISO(destPath string, sources map[string]string) error {
+	type isoFile struct {
+		shortName string
+		longName  string
+		data      []byte
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]isoFile, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(sources[name])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		files = append(files, isoFile{shortName: isoIdentifier(name), longName: filepath.Base(name), data: data})
+	}
+
+	// Lay out the volume: 16 reserved sectors, PVD, terminator, path
+	// tables (L then M), the root directory extent, then file data.
+	const (
+		pvdSector        = 16
+		termSector       = 17
+		pathTableLSector = 18
+		pathTableMSector = 19
+		rootDirSector    = 20
+		firstFileSector  = 21
+	)
+
+	rootDirBytes := dirRecord([]byte{0x00}, 0x02, rootDirSector, isoSectorSize, rockRidgeSPEntry())
+	rootDirBytes = append(rootDirBytes, dirRecord([]byte{0x01}, 0x02, rootDirSector, isoSectorSize, nil)...)
+
+	fileSector := uint32(firstFileSector)
+	for _, f := range files {
+		rootDirBytes = append(rootDirBytes, dirRecord([]byte(f.shortName), 0x00, fileSector, uint32(len(f.data)), rockRidgeNMEntry(f.longName))...)
+		fileSector += sectorsFor(len(f.data))
+	}
+	totalSectors := fileSector
+
+	pathTableSize := uint32(len(pathTableEntry(0, rootDirSector)))
+
+	pvd := make([]byte, isoSectorSize)
+	pvd[0] = 1
+	copy(pvd[1:6], "CD001")
+	pvd[6] = 1
+	copy(pvd[40:72], padISOString("CIDATA", 32))
+	copy(pvd[80:88], bothEndian32(totalSectors))
+	copy(pvd[120:124], bothEndian16(1))
+	copy(pvd[124:128], bothEndian16(1))
+	copy(pvd[128:132], bothEndian16(isoSectorSize))
+	copy(pvd[132:140], bothEndian32(pathTableSize))
+	binary.LittleEndian.PutUint32(pvd[140:144], pathTableLSector)
+	binary.BigEndian.PutUint32(pvd[148:152], pathTableMSector)
+
+	// The PVD's embedded root record (ECMA-119 8.4.14) occupies a fixed
+	// 34-byte slot; it must stay plain ISO9660 with no Rock Ridge System
+	// Use Area, since anything appended here would be truncated by the
+	// slot's fixed size and corrupt the record's length byte. SP belongs
+	// only in the "." entry of the root directory extent itself, below.
+	rootRecord := dirRecord([]byte{0x00}, 0x02, rootDirSector, isoSectorSize, nil)
+	copy(pvd[156:156+len(rootRecord)], rootRecord)
+
+	copy(pvd[190:318], padISOString("", 128))
+	now := isoDateTime()
+	copy(pvd[813:830], now)
+	pvd[881] = 1
+
+	term := make([]byte, isoSectorSize)
+	term[0] = 255
+	copy(term[1:6], "CD001")
+	term[6] = 1
+
+	pathTableL := make([]byte, isoSectorSize)
+	copy(pathTableL, pathTableEntry(0, rootDirSector))
+
+	pathTableM := make([]byte, isoSectorSize)
+	copy(pathTableM, pathTableEntryBE(0, rootDirSector))
+
+	rootDir := make([]byte, isoSectorSize)
+	copy(rootDir, rootDirBytes)
+
+	out := make([]byte, 0, totalSectors*isoSectorSize)
+	out = append(out, make([]byte, pvdSector*isoSectorSize)...)
+	out = append(out, pvd...)
+	out = append(out, term...)
+	out = append(out, pathTableL...)
+	out = append(out, pathTableM...)
+	out = append(out, rootDir...)
+
+	for _, f := range files {
+		padded := make([]byte, sectorsFor(len(f.data))*isoSectorSize)
+		copy(padded, f.data)
+		out = append(out, padded...)
+	}
+
+	return ioutil.WriteFile(destPath, out, 0644)
+}
+
+func sectorsFor(n int) uint32 {
+	if n == 0 {
+		return 1
+	}
+	return uint32((n + isoSectorSize - 1) / isoSectorSize)
+}
+
+// isoIdentifier upper-cases a file name into the 8.3-ish, ";1" versioned
+// form ISO9660 level 1 requires. This is only a fallback identifier: the
+// real, case-preserving long name is carried in the directory record's
+// Rock Ridge NM entry (see rockRidgeNMEntry), which is what the NoCloud
+// datasource actually reads.
+func isoIdentifier(name string) string {
+	base := filepath.Base(name)
+	upper := make([]byte, 0, len(base)+2)
+	for i := 0; i < len(base) && i < 8; i++ {
+		c := base[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = append(upper, c)
+	}
+	return string(upper) + ".;1"
+}
+
+func padISOString(s string, length int) []byte {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = ' '
+	}
+	copy(b, s)
+	return b
+}
+
+func bothEndian32(v uint32) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint32(b[0:4], v)
+	binary.BigEndian.PutUint32(b[4:8], v)
+	return b
+}
+
+func bothEndian16(v uint16) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b[0:2], v)
+	binary.BigEndian.PutUint16(b[2:4], v)
+	return b
+}
+
+// dirRecord encodes a single ISO9660 directory record (ECMA-119 9.1),
+// optionally followed by a Rock Ridge System Use Area (su) holding SUSP
+// entries such as SP or NM.
+func dirRecord(identifier []byte, flags byte, extentLBA, dataLength uint32, su []byte) []byte {
+	base := 33 + len(identifier)
+	if base%2 != 0 {
+		base++
+	}
+	recLen := base + len(su)
+	if recLen%2 != 0 {
+		recLen++
+	}
+
+	r := make([]byte, recLen)
+	r[0] = byte(recLen)
+	copy(r[2:10], bothEndian32(extentLBA))
+	copy(r[10:18], bothEndian32(dataLength))
+	copy(r[18:25], isoDateTime7())
+	r[25] = flags
+	copy(r[28:32], bothEndian16(1))
+	r[32] = byte(len(identifier))
+	copy(r[33:33+len(identifier)], identifier)
+	copy(r[base:base+len(su)], su)
+	return r
+}
+
+// rockRidgeSPEntry builds the SUSP "SP" system use entry that must be the
+// first entry in the root directory's "." record to signal that Rock Ridge
+// extensions are in use (SUSP 5.3). 0xBE, 0xEF is the fixed check-byte pair
+// SUSP mandates; BP_LEN (the number of bytes of padding before later system
+// use fields begin) is always 0 for this layout.
+func rockRidgeSPEntry() []byte {
+	return []byte{'S', 'P', 7, 1, 0xBE, 0xEF, 0}
+}
+
+// rockRidgeNMEntry builds the SUSP "NM" system use entry (RRIP 4.1.4) that
+// carries name's full, case-preserving POSIX name alongside a record's
+// truncated ISO9660 identifier.
+func rockRidgeNMEntry(name string) []byte {
+	length := 5 + len(name)
+	e := make([]byte, length)
+	e[0] = 'N'
+	e[1] = 'M'
+	e[2] = byte(length)
+	e[3] = 1
+	e[4] = 0
+	copy(e[5:], name)
+	return e
+}
+
+// pathTableEntry encodes a type-L (little endian) path table entry for the
+// root directory (ECMA-119 9.4). Only the root entry is needed since this
+// writer supports a single, flat directory.
+func pathTableEntry(parentIndex uint16, extentLBA uint32) []byte {
+	b := make([]byte, 10)
+	b[0] = 1
+	binary.LittleEndian.PutUint32(b[2:6], extentLBA)
+	binary.LittleEndian.PutUint16(b[6:8], parentIndex+1)
+	b[8] = 0
+	b[9] = 0
+	return b
+}
+
+func pathTableEntryBE(parentIndex uint16, extentLBA uint32) []byte {
+	b := make([]byte, 10)
+	b[0] = 1
+	binary.BigEndian.PutUint32(b[2:6], extentLBA)
+	binary.BigEndian.PutUint16(b[6:8], parentIndex+1)
+	b[8] = 0
+	b[9] = 0
+	return b
+}
+
+func isoDateTime() []byte {
+	return []byte("0000000000000000\x00")
+}
+
+// isoDateTime7 encodes a directory record's 7-byte recording date/time
+// (ECMA-119 9.1.5). Using the Unix epoch keeps the writer deterministic.
+func isoDateTime7() []byte {
+	t := time.Unix(0, 0).UTC()
+	return []byte{
+		byte(t.Year() - 1900),
+		byte(t.Month()),
+		byte(t.Day()),
+		byte(t.Hour()),
+		byte(t.Minute()),
+		byte(t.Second()),
+		0,
+	}
+}
@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readRockRidgeName extracts a Rock Ridge NM entry's name from a directory
+// record's System Use Area, if present, for use in round-trip assertions.
+func readRockRidgeName(record []byte) (string, bool) {
+	idLen := int(record[32])
+	suStart := 33 + idLen
+	if suStart%2 != 0 {
+		suStart++
+	}
+	su := record[suStart:]
+	for len(su) >= 5 {
+		if su[0] == 'N' && su[1] == 'M' {
+			length := int(su[2])
+			return string(su[5:length]), true
+		}
+		if su[2] == 0 {
+			break
+		}
+		su = su[su[2]:]
+	}
+	return "", false
+}
+
+func TestWriteNoCloudISO_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qemu-iso-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	userDataPath := filepath.Join(dir, "user-data")
+	metaDataPath := filepath.Join(dir, "meta-data")
+	userData := []byte("#cloud-config\nhostname: test\n")
+	metaData := []byte("instance-id: test-id\n")
+
+	if err := ioutil.WriteFile(userDataPath, userData, 0644); err != nil {
+		t.Fatalf("write user-data fixture: %v", err)
+	}
+	if err := ioutil.WriteFile(metaDataPath, metaData, 0644); err != nil {
+		t.Fatalf("write meta-data fixture: %v", err)
+	}
+
+	isoPath := filepath.Join(dir, "cidata.iso")
+	sources := map[string]string{
+		"user-data": userDataPath,
+		"meta-data": metaDataPath,
+	}
+	if err := writeNoCloudISO(isoPath, sources); err != nil {
+		t.Fatalf("writeNoCloudISO: %v", err)
+	}
+
+	raw, err := ioutil.ReadFile(isoPath)
+	if err != nil {
+		t.Fatalf("read generated ISO: %v", err)
+	}
+
+	const (
+		pvdSector     = 16
+		rootDirSector = 20
+	)
+	pvd := raw[pvdSector*isoSectorSize : (pvdSector+1)*isoSectorSize]
+
+	if !bytes.Equal(pvd[1:6], []byte("CD001")) {
+		t.Fatalf("PVD missing CD001 standard identifier")
+	}
+	if !bytes.Equal(bytes.TrimRight(pvd[40:72], " "), []byte("CIDATA")) {
+		t.Fatalf("PVD volume id = %q, want CIDATA", pvd[40:72])
+	}
+
+	rootRecordLen := int(pvd[156])
+	if rootRecordLen != 34 {
+		t.Fatalf("PVD embedded root record length = %d, want 34 (no Rock Ridge SU area)", rootRecordLen)
+	}
+
+	rootDir := raw[rootDirSector*isoSectorSize : (rootDirSector+1)*isoSectorSize]
+	dotRecordLen := int(rootDir[0])
+	dotRecord := rootDir[:dotRecordLen]
+	if name, ok := readRockRidgeName(dotRecord); ok {
+		t.Fatalf("root directory extent's \".\" record unexpectedly carries an NM name %q", name)
+	}
+
+	// SP must be the first SUSP entry in the root directory extent's "."
+	// record (SUSP 5.3); the PVD's own embedded root record must not carry
+	// one at all, asserted above via its 34-byte length.
+	suStart := 33 + int(dotRecord[32])
+	if suStart%2 != 0 {
+		suStart++
+	}
+	su := dotRecord[suStart:]
+	if len(su) < 2 || su[0] != 'S' || su[1] != 'P' {
+		t.Fatalf("root directory extent's \".\" record missing leading SUSP SP entry")
+	}
+
+	offset := dotRecordLen
+	offset += int(rootDir[offset]) // skip ".." record
+
+	wantFiles := map[string][]byte{
+		"meta-data": metaData,
+		"user-data": userData,
+	}
+	found := map[string]bool{}
+
+	for offset < isoSectorSize {
+		recLen := int(rootDir[offset])
+		if recLen == 0 {
+			break
+		}
+		record := rootDir[offset : offset+recLen]
+		name, ok := readRockRidgeName(record)
+		if !ok {
+			t.Fatalf("file record at offset %d missing Rock Ridge NM entry", offset)
+		}
+		want, ok := wantFiles[name]
+		if !ok {
+			t.Fatalf("unexpected file name %q in directory record", name)
+		}
+
+		var extentLBA, dataLen uint32
+		extentLBA = binary.LittleEndian.Uint32(record[2:6])
+		dataLen = binary.LittleEndian.Uint32(record[10:14])
+		got := raw[extentLBA*isoSectorSize : extentLBA*isoSectorSize+dataLen]
+		if !bytes.Equal(got, want) {
+			t.Fatalf("file %q contents = %q, want %q", name, got, want)
+		}
+		found[name] = true
+
+		offset += recLen
+	}
+
+	for name := range wantFiles {
+		if !found[name] {
+			t.Fatalf("file %q not found in generated ISO directory extent", name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/nomad/nomad/structs"
+)
+
+// Migration states recorded on qemuHandle/qemuId so progress survives a
+// client restart (Open) and is visible via MigrationStatus.
+const (
+	migrationStateNone         = ""
+	migrationStateSnapshotting = "snapshotting"
+	migrationStateMigrating    = "migrating"
+	migrationStateCompleted    = "completed"
+	migrationStateFailed       = "failed"
+)
+
+// migrationTimeout bounds how long Snapshot/Migrate wait for QMP to report
+// the migration as finished.
+const migrationTimeout = 10 * time.Minute
+
+// MigratableDriver is implemented by drivers that can snapshot a running
+// task's state to disk and migrate it, live, to another Nomad client. This
+// lets operators drain a node without cold-restarting long-running tasks.
+type MigratableDriver interface {
+	// Snapshot serializes the task's full VM state to dstPath on the local
+	// filesystem.
+	Snapshot(handle DriverHandle, dstPath string) error
+
+	// Restore starts a new task paused, waiting to receive the VM state a
+	// prior Snapshot wrote to srcPath on disk.
+	Restore(ctx *ExecContext, task *structs.Task, srcPath string) (DriverHandle, error)
+
+	// Listen starts a new task paused, listening on listenAddr ("host:port")
+	// for the VM state a peer client's Migrate call streams in live.
+	Listen(ctx *ExecContext, task *structs.Task, listenAddr string) (DriverHandle, error)
+
+	// Migrate live-migrates a running task directly to dstAddr
+	// ("host:port"), where a peer client has already called Listen with a
+	// matching address.
+	Migrate(handle DriverHandle, dstAddr string) error
+}
+
+// Snapshot serializes handle's VM state to dstPath via QMP, so it can later
+// be handed to Restore.
+func (d *QemuDriver) Snapshot(handle DriverHandle, dstPath string) error {
+	h, ok := handle.(*qemuHandle)
+	if !ok {
+		return fmt.Errorf("handle is not a qemu handle")
+	}
+	return h.snapshot(dstPath)
+}
+
+// Restore starts a new Qemu instance that waits to receive VM state
+// previously written by Snapshot at srcPath.
+func (d *QemuDriver) Restore(ctx *ExecContext, task *structs.Task, srcPath string) (DriverHandle, error) {
+	incoming := fmt.Sprintf("exec:gzip -c -d < %s", srcPath)
+	return d.start(ctx, task, incoming)
+}
+
+// Listen starts a new Qemu instance paused and listening on listenAddr,
+// ready to receive a live migration a peer client sends via Migrate.
+func (d *QemuDriver) Listen(ctx *ExecContext, task *structs.Task, listenAddr string) (DriverHandle, error) {
+	incoming := "tcp:" + listenAddr
+	return d.start(ctx, task, incoming)
+}
+
+// Migrate live-migrates handle's VM directly to dstAddr, where a peer
+// client is expected to already be running a Qemu instance started by
+// Listen with a matching address (`-incoming tcp:host:port`).
+func (d *QemuDriver) Migrate(handle DriverHandle, dstAddr string) error {
+	h, ok := handle.(*qemuHandle)
+	if !ok {
+		return fmt.Errorf("handle is not a qemu handle")
+	}
+	return h.migrate(dstAddr)
+}
+
+func (h *qemuHandle) snapshot(dstPath string) error {
+	client, err := dialQMP(h.qmpSocketPath, qmpDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to dial QMP socket %q: %v", h.qmpSocketPath, err)
+	}
+	defer client.Close()
+
+	h.setMigrationState(migrationStateSnapshotting)
+
+	if err := client.Migrate(fmt.Sprintf("exec:gzip -c > %s", dstPath)); err != nil {
+		h.setMigrationState(migrationStateFailed)
+		return fmt.Errorf("QMP migrate to %q failed: %v", dstPath, err)
+	}
+
+	return h.awaitMigration(client)
+}
+
+func (h *qemuHandle) migrate(dstAddr string) error {
+	client, err := dialQMP(h.qmpSocketPath, qmpDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to dial QMP socket %q: %v", h.qmpSocketPath, err)
+	}
+	defer client.Close()
+
+	h.setMigrationState(migrationStateMigrating)
+
+	if err := client.Migrate("tcp:" + dstAddr); err != nil {
+		h.setMigrationState(migrationStateFailed)
+		return fmt.Errorf("QMP migrate to %q failed: %v", dstAddr, err)
+	}
+
+	return h.awaitMigration(client)
+}
+
+// awaitMigration polls query-migrate until the in-flight migration
+// completes, fails, or migrationTimeout elapses.
+func (h *qemuHandle) awaitMigration(client *qmpClient) error {
+	deadline := time.After(migrationTimeout)
+	ticker := time.NewTicker(qmpPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadline:
+			h.setMigrationState(migrationStateFailed)
+			return fmt.Errorf("timed out waiting for migration to complete")
+		case <-ticker.C:
+			status, err := client.QueryMigrate()
+			if err != nil {
+				h.setMigrationState(migrationStateFailed)
+				return fmt.Errorf("query-migrate failed: %v", err)
+			}
+
+			switch status.Status {
+			case "completed":
+				h.setMigrationState(migrationStateCompleted)
+				return nil
+			case "failed", "cancelled":
+				h.setMigrationState(migrationStateFailed)
+				return fmt.Errorf("migration %s", status.Status)
+			}
+		}
+	}
+}
+
+// MigrationStatus reports the last known state of a Snapshot/Migrate
+// operation on this handle.
+func (h *qemuHandle) MigrationStatus() string {
+	h.migrationMu.Lock()
+	defer h.migrationMu.Unlock()
+	return h.migrationState
+}
+
+func (h *qemuHandle) setMigrationState(state string) {
+	h.migrationMu.Lock()
+	defer h.migrationMu.Unlock()
+	h.migrationState = state
+}
@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types/current"
+)
+
+const (
+	networkModeUser   = "user"
+	networkModeBridge = "bridge"
+	networkModeCNI    = "cni"
+
+	defaultCNIPath      = "/opt/cni/bin"
+	defaultCNIConfigDir = "/etc/cni/net.d"
+	defaultCNINetwork   = "nomad-bridge"
+
+	netnsDir = "/var/run/netns"
+)
+
+var qemuValidNetworkModes = map[string]bool{
+	"":                true,
+	networkModeUser:   true,
+	networkModeBridge: true,
+	networkModeCNI:    true,
+}
+
+// QemuNetworkConfig selects and configures the VM's networking mode. The
+// zero value preserves the legacy user-mode (SLIRP) networking.
+type QemuNetworkConfig struct {
+	Mode       string `mapstructure:"mode"`
+	CNINetwork string `mapstructure:"cni_network"`
+}
+
+// qemuNetwork is the result of attaching a VM to a CNI network: the TAP
+// device qemu should bridge onto, the IP(s) CNI's IPAM plugin allocated to
+// it, and enough state to tear the namespace back down or reattach to it
+// later.
+type qemuNetwork struct {
+	NetNS     string
+	TapDevice string
+	MAC       string
+	IPs       []string
+}
+
+// setupCNINetwork creates a dedicated network namespace, runs the named CNI
+// plugin chain inside it, and returns the TAP interface Qemu should attach
+// to. The executor is expected to launch Qemu inside the same namespace
+// (via nsenter) so the TAP device and route table it set up are visible.
+func setupCNINetwork(cniPath, cniConfigDir, cniNetwork, containerID string) (*qemuNetwork, error) {
+	if cniPath == "" {
+		cniPath = defaultCNIPath
+	}
+	if cniConfigDir == "" {
+		cniConfigDir = defaultCNIConfigDir
+	}
+	if cniNetwork == "" {
+		cniNetwork = defaultCNINetwork
+	}
+
+	netnsName := fmt.Sprintf("nomad-%s", containerID)
+	if err := exec.Command("ip", "netns", "add", netnsName).Run(); err != nil {
+		return nil, fmt.Errorf("failed to create network namespace %q: %v", netnsName, err)
+	}
+	netnsPath := filepath.Join(netnsDir, netnsName)
+
+	cninet := &libcni.CNIConfig{Path: []string{cniPath}}
+	netConfList, err := libcni.LoadConfList(cniConfigDir, cniNetwork)
+	if err != nil {
+		exec.Command("ip", "netns", "del", netnsName).Run()
+		return nil, fmt.Errorf("failed to load CNI network %q from %q: %v", cniNetwork, cniConfigDir, err)
+	}
+
+	rt := &libcni.RuntimeConf{
+		ContainerID: containerID,
+		NetNS:       netnsPath,
+		IfName:      "eth0",
+	}
+
+	res, err := cninet.AddNetworkList(netConfList, rt)
+	if err != nil {
+		exec.Command("ip", "netns", "del", netnsName).Run()
+		return nil, fmt.Errorf("failed to add CNI network %q: %v", cniNetwork, err)
+	}
+
+	result, err := current.NewResultFromResult(res)
+	if err != nil {
+		teardownCNINetwork(cniPath, cniConfigDir, cniNetwork, containerID, netnsName)
+		return nil, fmt.Errorf("failed to parse CNI result: %v", err)
+	}
+
+	var tap, mac string
+	for _, iface := range result.Interfaces {
+		if iface.Name == rt.IfName {
+			mac = iface.Mac
+			continue
+		}
+		// The host-side end of the pair is the interface CNI created
+		// outside of the sandboxed namespace -- that's the TAP device qemu
+		// attaches to from the host side.
+		if iface.Sandbox == "" {
+			tap = iface.Name
+		}
+	}
+	if tap == "" {
+		teardownCNINetwork(cniPath, cniConfigDir, cniNetwork, containerID, netnsName)
+		return nil, fmt.Errorf("CNI plugin %q did not return a host-side TAP interface", cniNetwork)
+	}
+
+	ips := make([]string, 0, len(result.IPs))
+	for _, ip := range result.IPs {
+		if ip.Address.IP != nil {
+			ips = append(ips, ip.Address.IP.String())
+		}
+	}
+
+	return &qemuNetwork{
+		NetNS:     netnsName,
+		TapDevice: tap,
+		MAC:       mac,
+		IPs:       ips,
+	}, nil
+}
+
+// teardownCNINetwork reverses setupCNINetwork: it runs the CNI DEL action
+// and removes the network namespace.
+func teardownCNINetwork(cniPath, cniConfigDir, cniNetwork, containerID, netnsName string) error {
+	if cniPath == "" {
+		cniPath = defaultCNIPath
+	}
+	if cniConfigDir == "" {
+		cniConfigDir = defaultCNIConfigDir
+	}
+	if cniNetwork == "" {
+		cniNetwork = defaultCNINetwork
+	}
+
+	netnsPath := filepath.Join(netnsDir, netnsName)
+	cninet := &libcni.CNIConfig{Path: []string{cniPath}}
+	if netConfList, err := libcni.LoadConfList(cniConfigDir, cniNetwork); err == nil {
+		rt := &libcni.RuntimeConf{ContainerID: containerID, NetNS: netnsPath, IfName: "eth0"}
+		cninet.DelNetworkList(netConfList, rt)
+	}
+
+	return exec.Command("ip", "netns", "del", netnsName).Run()
+}
+
+// nsenterArgs wraps a command so it runs inside the given network
+// namespace, which is how the executor launches Qemu for bridge/CNI
+// networking since the plugin process can't setns() itself after the fact.
+func nsenterArgs(netnsName string, cmd string, cmdArgs []string) (string, []string) {
+	netnsPath := filepath.Join(netnsDir, netnsName)
+	args := append([]string{"--net=" + netnsPath, "--", cmd}, cmdArgs...)
+	return "nsenter", args
+}
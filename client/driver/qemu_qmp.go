@@ -0,0 +1,177 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// qmpCommand is a single request sent over the QEMU Machine Protocol.
+type qmpCommand struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+// qmpResponse is the generic envelope QMP wraps every reply in.
+type qmpResponse struct {
+	Return json.RawMessage `json:"return"`
+	Error  *qmpError       `json:"error"`
+}
+
+// qmpEvent is the envelope QMP wraps asynchronous events in (e.g. STOP,
+// POWERDOWN, SHUTDOWN, MIGRATION). Events share the same newline-delimited
+// stream as command replies and can arrive interleaved with them at any
+// time after the capabilities handshake, so readResponse must recognize and
+// skip them rather than mistake one for the reply to the command just sent.
+type qmpEvent struct {
+	Event string `json:"event"`
+}
+
+type qmpError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+// qmpStatus is the subset of `query-status` the driver cares about.
+type qmpStatus struct {
+	Status  string `json:"status"`
+	Running bool   `json:"running"`
+}
+
+// qmpMigrationStatus is the subset of `query-migrate` the driver cares
+// about: whether a snapshot/live-migration triggered via "migrate" has
+// completed, failed, or is still in flight.
+type qmpMigrationStatus struct {
+	Status string `json:"status"`
+}
+
+// qmpClient speaks the QEMU Machine Protocol over a unix domain socket. QMP
+// is a newline delimited JSON protocol: the server greets the client on
+// connect, and after "qmp_capabilities" is acknowledged it accepts regular
+// commands.
+type qmpClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialQMP connects to the QMP socket at path and completes the
+// qmp_capabilities handshake.
+func dialQMP(path string, timeout time.Duration) (*qmpClient, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &qmpClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	// The server sends a greeting banner before it will accept commands.
+	if _, err := c.readResponse(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading QMP greeting: %v", err)
+	}
+
+	if _, err := c.execute("qmp_capabilities", nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error negotiating QMP capabilities: %v", err)
+	}
+
+	return c, nil
+}
+
+// readResponse reads lines off the QMP stream until it finds a command
+// reply, silently discarding any asynchronous events in between.
+func (c *qmpClient) readResponse() (*qmpResponse, error) {
+	for {
+		line, err := c.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		var evt qmpEvent
+		if err := json.Unmarshal(line, &evt); err == nil && evt.Event != "" {
+			continue
+		}
+
+		var resp qmpResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+}
+
+func (c *qmpClient) execute(cmd string, args interface{}) (*qmpResponse, error) {
+	req, err := json.Marshal(&qmpCommand{Execute: cmd, Arguments: args})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.conn.Write(append(req, '\n')); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("QMP command %q failed: %s", cmd, resp.Error.Desc)
+	}
+	return resp, nil
+}
+
+// SystemPowerdown requests an ACPI shutdown of the guest, giving it a chance
+// to exit cleanly rather than being killed outright.
+func (c *qmpClient) SystemPowerdown() error {
+	_, err := c.execute("system_powerdown", nil)
+	return err
+}
+
+// Quit forcibly terminates the Qemu process.
+func (c *qmpClient) Quit() error {
+	_, err := c.execute("quit", nil)
+	return err
+}
+
+// QueryStatus returns the guest's current run state.
+func (c *qmpClient) QueryStatus() (*qmpStatus, error) {
+	resp, err := c.execute("query-status", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var status qmpStatus
+	if err := json.Unmarshal(resp.Return, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Migrate kicks off a QMP "migrate" to the given URI, e.g.
+// "tcp:host:port" for a live migration to another host, or
+// "exec:gzip -c > file" to snapshot the VM's state to disk.
+func (c *qmpClient) Migrate(uri string) error {
+	_, err := c.execute("migrate", map[string]string{"uri": uri})
+	return err
+}
+
+// QueryMigrate returns the status of a migration previously started with
+// Migrate.
+func (c *qmpClient) QueryMigrate() (*qmpMigrationStatus, error) {
+	resp, err := c.execute("query-migrate", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var status qmpMigrationStatus
+	if err := json.Unmarshal(resp.Return, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *qmpClient) Close() error {
+	return c.conn.Close()
+}
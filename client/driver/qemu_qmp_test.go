@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestQmpClient_readResponse(t *testing.T) {
+	cases := []struct {
+		name       string
+		stream     string
+		wantReturn string
+		wantErr    bool
+	}{
+		{
+			name:       "bare return",
+			stream:     `{"return": {}}` + "\n",
+			wantReturn: `{}`,
+		},
+		{
+			name:       "event before return",
+			stream:     `{"event": "STOP", "timestamp": {"seconds": 1, "microseconds": 0}}` + "\n" + `{"return": {"status": "running"}}` + "\n",
+			wantReturn: `{"status": "running"}`,
+		},
+		{
+			name: "multiple interleaved events",
+			stream: `{"event": "MIGRATION", "data": {"status": "active"}}` + "\n" +
+				`{"event": "POWERDOWN"}` + "\n" +
+				`{"event": "SHUTDOWN", "data": {"guest": true}}` + "\n" +
+				`{"return": {"status": "shutdown"}}` + "\n",
+			wantReturn: `{"status": "shutdown"}`,
+		},
+		{
+			name:    "error reply",
+			stream:  `{"error": {"class": "GenericError", "desc": "boom"}}` + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &qmpClient{reader: bufio.NewReader(strings.NewReader(tc.stream))}
+			resp, err := c.readResponse()
+			if tc.wantErr {
+				if err == nil && (resp == nil || resp.Error == nil) {
+					t.Fatalf("expected an error response, got %+v, err=%v", resp, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(resp.Return) != tc.wantReturn {
+				t.Fatalf("got return %q, want %q", resp.Return, tc.wantReturn)
+			}
+		})
+	}
+}